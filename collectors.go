@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	collectorPeers = flag.Bool("collector.peers", false,
+		"Enable the peers collector (/net_info)")
+	collectorValidators = flag.Bool("collector.validators", false,
+		"Enable the validators collector (/validators)")
+	collectorMempool = flag.Bool("collector.mempool", false,
+		"Enable the mempool collector (/num_unconfirmed_txs)")
+	collectorABCI = flag.Bool("collector.abci", false,
+		"Enable the ABCI info collector (/abci_info)")
+	collectorConsensus = flag.Bool("collector.consensus", false,
+		"Enable the consensus state collector (/consensus_state)")
+
+	peersConnected = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "peers_connected"),
+		"Number of peers currently connected",
+		[]string{"target"}, nil,
+	)
+	validatorVotingPower = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "validator_voting_power"),
+		"Voting power of a validator in the active set",
+		[]string{"target", "address"}, nil,
+	)
+	validatorIsInActiveSet = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "validator_is_in_active_set"),
+		"Whether this node's validator address is part of the active validator set",
+		[]string{"target", "address"}, nil,
+	)
+	validatorMissedBlocks = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "validator_missed_blocks"),
+		"Best-effort count of scrapes during which this node's validator was not found in the active set",
+		[]string{"target", "address"}, nil,
+	)
+	mempoolSize = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "mempool_size"),
+		"Number of unconfirmed transactions in the mempool",
+		[]string{"target"}, nil,
+	)
+	mempoolBytes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "mempool_bytes"),
+		"Total size in bytes of unconfirmed transactions in the mempool",
+		[]string{"target"}, nil,
+	)
+	catchingUp = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "catching_up"),
+		"Whether the node is still catching up to the chain tip",
+		[]string{"target"}, nil,
+	)
+	appVersion = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "app_version"),
+		"Application version reported by abci_info, constant 1",
+		[]string{"target", "version"}, nil,
+	)
+	nodeInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "node_info"),
+		"Node information, constant 1",
+		[]string{"target", "moniker", "network", "version", "id"}, nil,
+	)
+	consensusHeight = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "consensus_height"),
+		"Current height reported by the consensus state machine",
+		[]string{"target"}, nil,
+	)
+	consensusRound = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "consensus_round"),
+		"Current round reported by the consensus state machine",
+		[]string{"target"}, nil,
+	)
+)
+
+// collectorSet is which optional collectors scrape runs for a given target.
+// The main scrape loop (see NewExporter in exporter.go) uses
+// defaultCollectorSet, built once from the --collector.* flags; probeHandler
+// builds one per probe module instead, so a module can opt a probed target
+// into a different set of collectors than the process-wide default.
+type collectorSet struct {
+	Peers, Validators, Mempool, ABCI, Consensus bool
+}
+
+// defaultCollectorSet reflects the process-wide --collector.* flags.
+func defaultCollectorSet() collectorSet {
+	return collectorSet{
+		Peers:      *collectorPeers,
+		Validators: *collectorValidators,
+		Mempool:    *collectorMempool,
+		ABCI:       *collectorABCI,
+		Consensus:  *collectorConsensus,
+	}
+}
+
+// parseCollectorSet turns a probe module's "collectors" list (collector
+// names matching the --collector.* flag suffixes, e.g. "peers",
+// "validators") into a collectorSet. An unknown name is ignored rather than
+// rejected, matching how LoadProbeModules treats unrecognized module keys.
+func parseCollectorSet(names []string) collectorSet {
+	var s collectorSet
+	for _, name := range names {
+		switch name {
+		case "peers":
+			s.Peers = true
+		case "validators":
+			s.Validators = true
+		case "mempool":
+			s.Mempool = true
+		case "abci":
+			s.ABCI = true
+		case "consensus":
+			s.Consensus = true
+		}
+	}
+	return s
+}
+
+// collectPeers queries /net_info and reports the number of connected peers.
+func (e *Exporter) collectPeers(ctx context.Context, t Target, ch chan<- prometheus.Metric) {
+	netInfo, err := e.rpcClients[t.Name].NetInfo(ctx)
+	if err != nil {
+		logger.Warn("peers collector failed", "target", t.Name, "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(peersConnected, prometheus.GaugeValue, float64(len(netInfo.Peers)), t.Name)
+}
+
+// validatorsPerPage is the page size requested from /validators. CometBFT
+// defaults to 30 and caps at 100; requesting the cap up front keeps the
+// page count down for the largest active sets still in use.
+const validatorsPerPage = 100
+
+// collectValidators queries /validators, paging through the full active set
+// (the RPC only returns validatorsPerPage at a time), and reports the
+// voting power of every validator plus whether selfAddress (this node's own
+// validator address, from /status) is among them. validatorMissedBlocks is
+// a best-effort proxy incremented once per scrape where selfAddress is
+// absent from the active set - core Tendermint RPC does not expose
+// per-block signing records, so a precise missed-block count isn't
+// available without also querying the application's slashing module.
+func (e *Exporter) collectValidators(ctx context.Context, t Target, ch chan<- prometheus.Metric, selfAddress string) {
+	inActiveSet := 0.0
+	perPage := validatorsPerPage
+	for page := 1; ; page++ {
+		result, err := e.rpcClients[t.Name].Validators(ctx, nil, &page, &perPage)
+		if err != nil {
+			logger.Warn("validators collector failed", "target", t.Name, "error", err)
+			return
+		}
+
+		for _, v := range result.Validators {
+			address := v.Address.String()
+			ch <- prometheus.MustNewConstMetric(validatorVotingPower, prometheus.GaugeValue, float64(v.VotingPower), t.Name, address)
+			if address == selfAddress {
+				inActiveSet = 1
+			}
+		}
+
+		if page*perPage >= result.Total {
+			break
+		}
+	}
+
+	if selfAddress == "" {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(validatorIsInActiveSet, prometheus.GaugeValue, inActiveSet, t.Name, selfAddress)
+
+	missed := e.bumpMissedBlocks(t.Name, inActiveSet == 0)
+	ch <- prometheus.MustNewConstMetric(validatorMissedBlocks, prometheus.CounterValue, float64(missed), t.Name, selfAddress)
+}
+
+// collectMempool queries /num_unconfirmed_txs and reports mempool size.
+func (e *Exporter) collectMempool(ctx context.Context, t Target, ch chan<- prometheus.Metric) {
+	result, err := e.rpcClients[t.Name].UnconfirmedTxs(ctx, nil)
+	if err != nil {
+		logger.Warn("mempool collector failed", "target", t.Name, "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(mempoolSize, prometheus.GaugeValue, float64(result.Total), t.Name)
+	ch <- prometheus.MustNewConstMetric(mempoolBytes, prometheus.GaugeValue, float64(result.TotalBytes), t.Name)
+}
+
+// collectABCI queries /abci_info and reports the application version.
+func (e *Exporter) collectABCI(ctx context.Context, t Target, ch chan<- prometheus.Metric) {
+	result, err := e.rpcClients[t.Name].ABCIInfo(ctx)
+	if err != nil {
+		logger.Warn("abci collector failed", "target", t.Name, "error", err)
+		return
+	}
+
+	version := result.Response.Version
+	if result.Response.AppVersion > 0 {
+		version = fmt.Sprintf("%d", result.Response.AppVersion)
+	}
+	ch <- prometheus.MustNewConstMetric(appVersion, prometheus.GaugeValue, 1, t.Name, version)
+}
+
+// roundStateSimple mirrors the subset of /consensus_state's round_state
+// object this collector cares about. The RPC returns round_state as raw
+// JSON rather than a typed struct, so only the height_round_step field -
+// a stable "<height>/<round>/<step>" string - is decoded here.
+type roundStateSimple struct {
+	HeightRoundStep string `json:"height_round_step"`
+}
+
+// collectConsensus queries /consensus_state and reports the current height
+// and round of the consensus state machine, as distinct from the last
+// committed block height already reported by latestBlockHeight.
+func (e *Exporter) collectConsensus(ctx context.Context, t Target, ch chan<- prometheus.Metric) {
+	result, err := e.rpcClients[t.Name].ConsensusState(ctx)
+	if err != nil {
+		logger.Warn("consensus collector failed", "target", t.Name, "error", err)
+		return
+	}
+
+	var round roundStateSimple
+	if err := json.Unmarshal(result.RoundState, &round); err != nil {
+		logger.Warn("parsing consensus_state round_state", "target", t.Name, "error", err)
+		return
+	}
+
+	parts := strings.SplitN(round.HeightRoundStep, "/", 3)
+	if len(parts) != 3 {
+		logger.Warn("unexpected height_round_step format", "target", t.Name, "value", round.HeightRoundStep)
+		return
+	}
+	height, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		logger.Warn("parsing consensus height", "target", t.Name, "error", err)
+		return
+	}
+	r, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		logger.Warn("parsing consensus round", "target", t.Name, "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(consensusHeight, prometheus.GaugeValue, height, t.Name)
+	ch <- prometheus.MustNewConstMetric(consensusRound, prometheus.GaugeValue, r, t.Name)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	tmtypes "github.com/cometbft/cometbft/types"
+)
+
+// rpcMode selects how the exporter keeps latestBlockHeight/timeDiff fresh.
+type rpcMode string
+
+const (
+	rpcModePoll      rpcMode = "poll"
+	rpcModeSubscribe rpcMode = "subscribe"
+	rpcModeHybrid    rpcMode = "hybrid"
+)
+
+var rpcModeFlag = flag.String("rpc.mode", string(rpcModePoll),
+	"How to keep block height/time fresh: poll, subscribe, or hybrid")
+
+const (
+	newBlockQuery            = "tm.event='NewBlock'"
+	validatorSetUpdatesQuery = "tm.event='ValidatorSetUpdates'"
+	subscriberID             = "cosmos_exporter"
+
+	// liveStaleAfter bounds how long a pushed value is trusted before
+	// scrape() falls back to what it just polled from /status.
+	liveStaleAfter = 30 * time.Second
+)
+
+// lastValidatorSetUpdate records, per target name, the time of the last
+// ValidatorSetUpdates event observed over the WebSocket subscription.
+var lastValidatorSetUpdate sync.Map
+
+var validatorSetLastUpdate = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "validator_set_last_update_timestamp_seconds"),
+	"Unix timestamp of the last ValidatorSetUpdates event observed over the WebSocket subscription",
+	[]string{"target"}, nil,
+)
+
+// liveState holds the latest block height/time pushed over a target's
+// WebSocket subscription, so scrape() can serve sub-second-fresh values
+// without waiting on the next /status poll.
+type liveState struct {
+	mu        sync.RWMutex
+	height    int64
+	blockTime time.Time
+	updatedAt time.Time
+}
+
+func (s *liveState) setBlock(height int64, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.height = height
+	s.blockTime = t
+	s.updatedAt = time.Now()
+}
+
+// snapshot returns the last pushed height/time and whether it is still
+// fresh enough to trust.
+func (s *liveState) snapshot() (height int64, blockTime time.Time, fresh bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.height, s.blockTime, !s.updatedAt.IsZero() && time.Since(s.updatedAt) < liveStaleAfter
+}
+
+// subscribeTarget starts client's WebSocket connection and feeds NewBlock
+// events into live. It also subscribes to ValidatorSetUpdates purely to
+// surface validatorSetLastUpdate - the event only carries a diff of the
+// active set, not full per-validator voting power, so it isn't a reliable
+// source for validatorVotingPower, which collectValidators still derives
+// from a plain /validators call.
+func subscribeTarget(t Target, client rpcclient.Client, live *liveState) {
+	if err := client.Start(); err != nil {
+		logger.Warn("starting websocket client", "target", t.Name, "error", err)
+		return
+	}
+
+	ctx := context.Background()
+
+	blocks, err := client.Subscribe(ctx, subscriberID, newBlockQuery)
+	if err != nil {
+		logger.Warn("subscribing to query", "target", t.Name, "query", newBlockQuery, "error", err)
+	} else {
+		go func() {
+			for evt := range blocks {
+				data, ok := evt.Data.(tmtypes.EventDataNewBlock)
+				if !ok || data.Block == nil {
+					continue
+				}
+				live.setBlock(data.Block.Height, data.Block.Time)
+			}
+		}()
+	}
+
+	updates, err := client.Subscribe(ctx, subscriberID, validatorSetUpdatesQuery)
+	if err != nil {
+		logger.Warn("subscribing to query", "target", t.Name, "query", validatorSetUpdatesQuery, "error", err)
+		return
+	}
+	go func() {
+		for range updates {
+			lastValidatorSetUpdate.Store(t.Name, time.Now())
+		}
+	}()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultScrapeTimeout  = model.Duration(10 * time.Second)
+	defaultMaxConcurrency = 10
+)
+
+// TLSConfig holds the TLS client settings used to scrape a single target.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+}
+
+// Target describes a single named Cosmos RPC endpoint to scrape.
+type Target struct {
+	Name    string            `yaml:"name" json:"name"`
+	URL     string            `yaml:"url" json:"url"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	TLS     *TLSConfig        `yaml:"tls_config,omitempty" json:"tls_config,omitempty"`
+}
+
+// Config is the top-level document read from -config.file.
+type Config struct {
+	ScrapeTimeout  model.Duration `yaml:"scrape_timeout" json:"scrape_timeout"`
+	MaxConcurrency int            `yaml:"max_concurrency" json:"max_concurrency"`
+	Targets        []Target       `yaml:"targets" json:"targets"`
+}
+
+// LoadConfig reads and validates the target configuration file at path.
+// The format is selected from the file extension: ".json" is parsed as
+// JSON, anything else as YAML.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		ScrapeTimeout:  defaultScrapeTimeout,
+		MaxConcurrency: defaultMaxConcurrency,
+	}
+
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, cfg)
+	} else {
+		err = yaml.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = defaultMaxConcurrency
+	}
+
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("config file defines no targets")
+	}
+	seen := make(map[string]bool, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		if t.Name == "" || t.URL == "" {
+			return nil, fmt.Errorf("target %d: name and url are required", i)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("target %d: duplicate target name %q", i, t.Name)
+		}
+		seen[t.Name] = true
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - name: a
+    url: http://a:26657
+  - name: b
+    url: http://b:26657
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("got %d targets, want 2", len(cfg.Targets))
+	}
+	if cfg.MaxConcurrency != defaultMaxConcurrency {
+		t.Errorf("MaxConcurrency = %d, want default %d", cfg.MaxConcurrency, defaultMaxConcurrency)
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"targets": [{"name": "a", "url": "http://a:26657"}]
+	}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Targets) != 1 {
+		t.Fatalf("got %d targets, want 1", len(cfg.Targets))
+	}
+}
+
+func TestLoadConfigNoTargets(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `targets: []`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected an error for a config file with no targets")
+	}
+}
+
+func TestLoadConfigMissingFields(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - name: a
+`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected an error for a target missing url")
+	}
+}
+
+func TestLoadConfigDuplicateNames(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - name: a
+    url: http://a:26657
+  - name: a
+    url: http://b:26657
+`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected an error for duplicate target names")
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected an error for a missing config file")
+	}
+}
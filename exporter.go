@@ -1,185 +1,362 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
-	"strconv"
-	"encoding/json"
-        "time"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-type ProtocolVersion struct {
-  P2p	string `json:"p2p"`
-  Block string `json:"block"`
-  App 	string `json:"app"`
-}
-
-type Other struct {
-  TxIndex 	string `json:"tx_index"`
-  RpcAddress 	string `json:"rpc_address"`
-}
-
-type PubKey struct {
-  Type 	string `json:"type"`
-  Value string `json:"value"`
-}
-
-type NodeInfo struct {
-  ProtoVer 	ProtocolVersion `json:"protocol_version"`
-  Id 		string `json:"id"`
-  ListenAddr 	string `json:"listen_addr"`
-  Network 	string `json:"network"`
-  Version 	string `json:"version"`
-  Channels 	string `json:"channels"`
-  Moniker 	string `json:"moniker"`
-  InfoOther 	Other `json:"other"`
-}
-
-type SyncInfo struct {
-  LatestBlockHash 	string `json:"latest_block_hash"`
-  LatestAppHash 	string `json:"latest_app_hash"`
-  LatestBlockHeight 	string `json:"latest_block_height"`
-  LatestBlockTime 	string `json:"latest_block_time"`
-  EarlestBlockHash 	string `json:"earlest_block_hash"`
-  EarlestAppHash 	string `json:"earlest_app_hash"`
-  EarlestBlockHeight 	string `json:"earlest_block_height"`
-  EarlestBlockTime 	string `json:"earlest_block_time"`
-  CatchingUp 		bool `json:"catching_up"`
-}
+	"github.com/prometheus/common/version"
 
-type ValidatorInfo struct {
-  Address 	string `json:"address"`
-  InfoPubKey  	PubKey `json:"pub_key"`
-  VotingPower 	string  `json:"voting_power"`
-}
-
-type Result struct {
-  MessageNodeInfo 	NodeInfo `json:"node_info"`
-  MessageSyncInfo 	SyncInfo `json:"sync_info"`
-  MessageValidatorInfo  ValidatorInfo `json:"validator_info"`
-}
-
-type Message struct {
-  Jsonrpc   	string `json:"jsonrpc"`
-  Id   		int64  `json:"id"`
-  MessageResult Result `json:"result"`
-}
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+)
 
 const namespace = "cosmos"
-const url = "/status"
 
 var (
-	client = &http.Client{Timeout: 10 * time.Second}
 	listenAddress = flag.String("web.listen-address", ":9141",
 		"Address to listen on for telemetry")
 	metricsPath = flag.String("web.telemetry-path", "/metrics",
 		"Path under which to expose metrics")
+	configFile = flag.String("config.file", "cosmos_exporter.yml",
+		"Path to the target configuration file")
+	probeConfigFile = flag.String("probe.config.file", "",
+		"Optional path to a probe module configuration file")
+	shutdownTimeout = flag.Duration("web.shutdown-timeout", 30*time.Second,
+		"Maximum time to wait for in-flight scrapes to finish on shutdown")
 
 	// Metrics
 	up = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "", "up"),
 		"Was the last cosmos query successful.",
-		nil, nil,
+		[]string{"target"}, nil,
 	)
 	latestBlockHeight = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "", "latest_block_height"),
 		"Latest block height",
-		[]string{"node"}, nil,
+		[]string{"target"}, nil,
 	)
 	timeDiff = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "", "time_diff"),
 		"Time difference",
-		[]string{"node"}, nil,
+		[]string{"target"}, nil,
 	)
 )
 
+// Exporter scrapes a set of named Cosmos RPC endpoints and exposes their
+// status as Prometheus metrics, labeled by target name. RPC access goes
+// through the upstream CometBFT client (rpcClients), which also gives the
+// optional WebSocket subscription mode (see cometbft.go) typed access to
+// push events without any hand-rolled JSON decoding.
 type Exporter struct {
-	cosmosEndpoint string
+	targets       []Target
+	clients       map[string]*http.Client
+	rpcClients    map[string]rpcclient.Client
+	live          map[string]*liveState
+	mode          rpcMode
+	scrapeTimeout time.Duration
+	shards        []*shard
+	missedBlocks  sync.Map // target name -> *uint64
+	errorCounts   sync.Map // "target|reason" -> *uint64
+	collectors    collectorSet
 }
 
-func NewExporter(cosmosEndpoint string) *Exporter {
-	return &Exporter{
-		cosmosEndpoint: cosmosEndpoint,
+// NewExporter builds an Exporter for every target in cfg: a plain
+// http.Client per target (for TLS and custom headers), a CometBFT RPC
+// client layered on top of it, and - depending on --rpc.mode - a live
+// WebSocket subscription. It then starts the sharded scrape workers (see
+// scrape.go).
+func NewExporter(cfg *Config) (*Exporter, error) {
+	scrapeTimeout := time.Duration(cfg.ScrapeTimeout)
+	mode := rpcMode(*rpcModeFlag)
+
+	e := &Exporter{
+		targets:       cfg.Targets,
+		clients:       make(map[string]*http.Client, len(cfg.Targets)),
+		rpcClients:    make(map[string]rpcclient.Client, len(cfg.Targets)),
+		live:          make(map[string]*liveState, len(cfg.Targets)),
+		mode:          mode,
+		scrapeTimeout: scrapeTimeout,
+		collectors:    defaultCollectorSet(),
 	}
+
+	for _, t := range cfg.Targets {
+		httpClient, err := newTargetClient(t, scrapeTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		e.clients[t.Name] = httpClient
+
+		rpc, err := rpchttp.NewWithClient(t.URL, "/websocket", httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: building RPC client: %w", t.Name, err)
+		}
+		e.rpcClients[t.Name] = rpc
+
+		if mode != rpcModePoll {
+			live := &liveState{}
+			e.live[t.Name] = live
+			go subscribeTarget(t, rpc, live)
+		}
+	}
+
+	perShardConcurrency := cfg.MaxConcurrency / *scrapeShards
+	if perShardConcurrency < 1 {
+		perShardConcurrency = 1
+	}
+	e.shards = newShards(*scrapeShards, perShardConcurrency, *scrapeBatchSize, *scrapeBatchDeadline, e.scrape, e.recordResult)
+
+	return e, nil
+}
+
+// headerRoundTripper injects a target's static headers into every request,
+// since the upstream RPC client builds its own requests internally and has
+// no per-call hook for custom headers.
+type headerRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	return h.next.RoundTrip(req)
+}
+
+// newTargetClient builds the http.Client used to talk to t, configuring
+// TLS from t.TLS and header injection from t.Headers when present.
+func newTargetClient(t Target, timeout time.Duration) (*http.Client, error) {
+	client := &http.Client{Timeout: timeout}
+
+	if t.TLS != nil {
+		tlsConfig := &tls.Config{InsecureSkipVerify: t.TLS.InsecureSkipVerify}
+
+		if t.TLS.CertFile != "" && t.TLS.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(t.TLS.CertFile, t.TLS.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		if t.TLS.CAFile != "" {
+			caCert, err := ioutil.ReadFile(t.TLS.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading ca_file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("no certificates found in %s", t.TLS.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	if len(t.Headers) > 0 {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		client.Transport = &headerRoundTripper{headers: t.Headers, next: next}
+	}
+
+	return client, nil
+}
+
+// bumpMissedBlocks increments and returns the running missed-block counter
+// for target when missed is true, otherwise it just returns the current
+// count unchanged.
+func (e *Exporter) bumpMissedBlocks(target string, missed bool) uint64 {
+	counter, _ := e.missedBlocks.LoadOrStore(target, new(uint64))
+	c := counter.(*uint64)
+	if missed {
+		return atomic.AddUint64(c, 1)
+	}
+	return atomic.LoadUint64(c)
 }
 
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- up
 	ch <- latestBlockHeight
 	ch <- timeDiff
+	ch <- nodeInfo
+	ch <- catchingUp
+	ch <- peersConnected
+	ch <- validatorVotingPower
+	ch <- validatorIsInActiveSet
+	ch <- validatorMissedBlocks
+	ch <- mempoolSize
+	ch <- mempoolBytes
+	ch <- appVersion
+	ch <- consensusHeight
+	ch <- consensusRound
+	ch <- scrapeErrorsTotal
+	ch <- scrapeDuration
+	ch <- lastScrapeTimestamp
+	ch <- validatorSetLastUpdate
 }
 
+// Collect hands one scrape job per target to its shard (see scrape.go) and
+// waits for all of them to finish before returning.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	req, err := http.NewRequest("GET", e.cosmosEndpoint+url, nil)
-	if err != nil {
-		log.Fatal(err)
+	var wg sync.WaitGroup
+
+	for _, t := range e.targets {
+		wg.Add(1)
+		idx := shardIndex(t.Name, len(e.shards))
+		e.shards[idx].jobs <- scrapeJob{target: t, ch: ch, wg: &wg}
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		ch <- prometheus.MustNewConstMetric(
-			up, prometheus.GaugeValue, 0,
-		)
-		log.Println(err)
-		return
-	}
-	ch <- prometheus.MustNewConstMetric(
-		up, prometheus.GaugeValue, 1,
-	)
+	wg.Wait()
+}
+
+// scrape performs a single scrape of t, sending its metrics on ch, and
+// reports whether the target was up and, if not, a short failure reason.
+// It never calls log.Fatal: a failed scrape marks that target down via
+// up=0 rather than crashing every other target along with it. It is used
+// both by the shard workers in scrape.go, which fan it out across all
+// configured targets, and by probeHandler, which calls it directly against
+// a single ad-hoc target.
+func (e *Exporter) scrape(t Target, ch chan<- prometheus.Metric) (bool, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.scrapeTimeout)
+	defer cancel()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
+	status, err := e.rpcClients[t.Name].Status(ctx)
 	if err != nil {
-		log.Fatal(err)
+		ch <- prometheus.MustNewConstMetric(up, prometheus.GaugeValue, 0, t.Name)
+		logger.Warn("scrape failed", "target", t.Name, "error", err)
+		return false, "request_error"
 	}
+	ch <- prometheus.MustNewConstMetric(up, prometheus.GaugeValue, 1, t.Name)
 
-        message := Message{}
-        jsonErr := json.Unmarshal(body, &message)
-	if jsonErr != nil {
-		log.Fatal(jsonErr)
+	height := status.SyncInfo.LatestBlockHeight
+	blockTime := status.SyncInfo.LatestBlockTime
+
+	if live, ok := e.live[t.Name]; ok {
+		if liveHeight, liveTime, fresh := live.snapshot(); fresh {
+			switch e.mode {
+			case rpcModeSubscribe:
+				height, blockTime = liveHeight, liveTime
+			case rpcModeHybrid:
+				if liveTime.After(blockTime) {
+					height, blockTime = liveHeight, liveTime
+				}
+			}
+		}
 	}
+	ch <- prometheus.MustNewConstMetric(latestBlockHeight, prometheus.GaugeValue, float64(height), t.Name)
+	ch <- prometheus.MustNewConstMetric(timeDiff, prometheus.GaugeValue, float64(time.Since(blockTime).Seconds()), t.Name)
 
-	channellatestBlockHeight, _ := strconv.ParseFloat(message.MessageResult.MessageSyncInfo.LatestBlockHeight, 64)
-	ch <- prometheus.MustNewConstMetric(latestBlockHeight, prometheus.GaugeValue, channellatestBlockHeight, "localhost")
+	info := status.NodeInfo
+	ch <- prometheus.MustNewConstMetric(nodeInfo, prometheus.GaugeValue, 1, t.Name, info.Moniker, info.Network, info.Version, string(info.ID()))
 
-        layout := "2006-01-02T15:04:05.999999999Z07:00"
-        t, err := time.Parse(layout, message.MessageResult.MessageSyncInfo.LatestBlockTime)
-	if err != nil {
-		log.Println(err)
+	catchingUpValue := 0.0
+	if status.SyncInfo.CatchingUp {
+		catchingUpValue = 1
 	}
+	ch <- prometheus.MustNewConstMetric(catchingUp, prometheus.GaugeValue, catchingUpValue, t.Name)
 
-        now := time.Now()
-        secs := now.Unix()
-        diff := secs - t.Unix()
+	if ts, ok := lastValidatorSetUpdate.Load(t.Name); ok {
+		ch <- prometheus.MustNewConstMetric(validatorSetLastUpdate, prometheus.GaugeValue, float64(ts.(time.Time).Unix()), t.Name)
+	}
 
-	channeltimeDiff, _ := strconv.ParseFloat(strconv.FormatInt(diff, 10), 64)
-        ch <- prometheus.MustNewConstMetric(timeDiff, prometheus.GaugeValue, channeltimeDiff, "localhost")
+	if e.collectors.Peers {
+		e.collectPeers(ctx, t, ch)
+	}
+	if e.collectors.Validators {
+		e.collectValidators(ctx, t, ch, status.ValidatorInfo.Address.String())
+	}
+	if e.collectors.Mempool {
+		e.collectMempool(ctx, t, ch)
+	}
+	if e.collectors.ABCI {
+		e.collectABCI(ctx, t, ch)
+	}
+	if e.collectors.Consensus {
+		e.collectConsensus(ctx, t, ch)
+	}
 
-	log.Println("Endpoint scraped")
+	logger.Debug("target scraped", "target", t.Name)
+	return true, ""
 }
 
-func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Error loading .env file, assume env variables are set.")
+// loadConfigOrFallback reads the target configuration file, falling back to
+// a single target built from COSMOS_ENDPOINT when the file does not exist,
+// so existing single-endpoint deployments keep working untouched.
+func loadConfigOrFallback(path string) (*Config, error) {
+	cfg, err := LoadConfig(path)
+	if err == nil {
+		return cfg, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	endpoint := os.Getenv("COSMOS_ENDPOINT")
+	if endpoint == "" {
+		return nil, err
 	}
 
+	logger.Info("config file not found, falling back to COSMOS_ENDPOINT", "path", path)
+	return &Config{
+		ScrapeTimeout:  defaultScrapeTimeout,
+		MaxConcurrency: defaultMaxConcurrency,
+		Targets:        []Target{{Name: "localhost", URL: endpoint}},
+	}, nil
+}
+
+func main() {
 	flag.Parse()
+	logger = newLogger(*logLevel, *logFormat)
+
+	if err := godotenv.Load(); err != nil {
+		logger.Info("no .env file found, assuming environment variables are set")
+	}
 
-	cosmosEndpoint := os.Getenv("COSMOS_ENDPOINT")
+	cfg, err := loadConfigOrFallback(*configFile)
+	if err != nil {
+		logger.Error("loading config", "error", err)
+		os.Exit(1)
+	}
+
+	exporter, err := NewExporter(cfg)
+	if err != nil {
+		logger.Error("building exporter", "error", err)
+		os.Exit(1)
+	}
+	prometheus.MustRegister(exporter, prometheus.NewGoCollector(), version.NewCollector("cosmos_exporter"))
 
-	exporter := NewExporter(cosmosEndpoint)
-	prometheus.MustRegister(exporter)
+	probeModules, err := LoadProbeModules(*probeConfigFile)
+	if err != nil {
+		logger.Error("loading probe config", "error", err)
+		os.Exit(1)
+	}
 
-	http.Handle(*metricsPath, promhttp.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.Handle(*metricsPath, promhttp.Handler())
+	mux.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		probeHandler(w, r, probeModules)
+	})
+	mux.HandleFunc("/-/healthy", healthyHandler)
+	mux.HandleFunc("/-/ready", readyHandler)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Dummy Cosmos Exporter</title></head>
              <body>
@@ -188,5 +365,28 @@ func main() {
              </body>
              </html>`))
 	})
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+
+	server := &http.Server{Addr: *listenAddress, Handler: mux}
+
+	go func() {
+		atomic.StoreInt32(&ready, 1)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("http server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	logger.Info("shutdown signal received, draining in-flight scrapes")
+	atomic.StoreInt32(&ready, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("graceful shutdown failed", "error", err)
+		os.Exit(1)
+	}
 }
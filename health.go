@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ready flips to 1 once the exporter is serving traffic and back to 0 as
+// soon as a shutdown signal is received, so /-/ready fails fast during
+// termination instead of racing the in-flight Shutdown call.
+var ready int32
+
+func healthyHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+)
+
+var (
+	logLevel = flag.String("log.level", "info",
+		"Minimum log level to emit: debug, info, warn, or error")
+	logFormat = flag.String("log.format", "logfmt",
+		"Log output format: logfmt or json")
+)
+
+// logger is the process-wide structured logger. It starts out as a
+// reasonable default so that anything logged before flags are parsed
+// still goes somewhere, and is replaced in main once --log.level and
+// --log.format are known.
+var logger = slog.Default()
+
+func newLogger(level, format string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	return slog.New(handler)
+}
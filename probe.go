@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+)
+
+// ProbeModule configures how /probe should scrape a target when no
+// equivalent setting is already baked into the target itself.
+type ProbeModule struct {
+	ScrapeTimeout model.Duration    `yaml:"scrape_timeout,omitempty"`
+	Headers       map[string]string `yaml:"headers,omitempty"`
+	TLS           *TLSConfig        `yaml:"tls_config,omitempty"`
+
+	// Collectors selects which optional collectors run for this module, by
+	// the same names as the --collector.* flag suffixes (e.g. "peers",
+	// "validators"). If nil, the module falls back to the process-wide
+	// --collector.* flags, same as the main scrape loop.
+	Collectors []string `yaml:"collectors,omitempty"`
+}
+
+// LoadProbeModules reads the probe module configuration file at path, if
+// any, and always returns at least a "default" module so that /probe works
+// out of the box with no configuration.
+func LoadProbeModules(path string) (map[string]ProbeModule, error) {
+	modules := map[string]ProbeModule{"default": {}}
+	if path == "" {
+		return modules, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Modules map[string]ProbeModule `yaml:"modules"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing probe config file %s: %w", path, err)
+	}
+	for name, m := range doc.Modules {
+		modules[name] = m
+	}
+
+	return modules, nil
+}
+
+// constCollector replays a fixed slice of already-collected metrics. It is
+// an "unchecked" collector (it describes nothing), which is the documented
+// escape hatch for metrics whose descriptors aren't known until scrape
+// time - exactly the case for an ad-hoc, per-request probe exporter.
+type constCollector struct {
+	metrics []prometheus.Metric
+}
+
+func (c *constCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *constCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+// probeHandler serves /probe?target=<rpc-url>&module=<name>. It builds an
+// ad-hoc, single-target Exporter for the requested URL, scrapes it once
+// using the named module's collector selection (falling back to the
+// process-wide --collector.* flags if the module doesn't specify one), and
+// returns the resulting metrics alongside cosmos_probe_success and
+// cosmos_probe_duration_seconds - mirroring blackbox_exporter, so that
+// Prometheus can discover Cosmos nodes via relabel_configs instead of
+// listing them in this exporter's own config file.
+func probeHandler(w http.ResponseWriter, r *http.Request, modules map[string]ProbeModule) {
+	params := r.URL.Query()
+
+	targetURL := params.Get("target")
+	if targetURL == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := params.Get("module")
+	if moduleName == "" {
+		moduleName = "default"
+	}
+	module, ok := modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	scrapeTimeout := time.Duration(module.ScrapeTimeout)
+	if scrapeTimeout <= 0 {
+		scrapeTimeout = time.Duration(defaultScrapeTimeout)
+	}
+
+	target := Target{Name: targetURL, URL: targetURL, Headers: module.Headers, TLS: module.TLS}
+
+	client, err := newTargetClient(target, scrapeTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rpc, err := rpchttp.NewWithClient(targetURL, "/websocket", client)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	collectors := defaultCollectorSet()
+	if module.Collectors != nil {
+		collectors = parseCollectorSet(module.Collectors)
+	}
+
+	exporter := &Exporter{
+		targets:       []Target{target},
+		clients:       map[string]*http.Client{targetURL: client},
+		rpcClients:    map[string]rpcclient.Client{targetURL: rpc},
+		scrapeTimeout: scrapeTimeout,
+		collectors:    collectors,
+	}
+
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cosmos_probe_success",
+		Help: "Whether the probe succeeded",
+	})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cosmos_probe_duration_seconds",
+		Help: "How long the probe took, in seconds",
+	})
+
+	start := time.Now()
+
+	// scrape() can emit more metrics than any fixed buffer would comfortably
+	// hold once the optional collectors are enabled, so it must run
+	// concurrently with the drain below rather than before it - otherwise
+	// it blocks forever on the first send past the channel's capacity.
+	ch := make(chan prometheus.Metric, 8)
+	var success bool
+	go func() {
+		defer close(ch)
+		success, _ = exporter.scrape(target, ch)
+	}()
+
+	metrics := make([]prometheus.Metric, 0, 8)
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+
+	probeDuration.Set(time.Since(start).Seconds())
+	if success {
+		probeSuccess.Set(1)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(probeSuccess, probeDuration, &constCollector{metrics: metrics})
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scrapeShards = flag.Int("scrape.shards", 4,
+		"Number of shards to spread concurrent target scraping across")
+	scrapeBatchSize = flag.Int("scrape.batch-size", 8,
+		"Maximum number of targets a shard scrapes before flushing")
+	scrapeBatchDeadline = flag.Duration("scrape.batch-deadline", time.Second,
+		"Maximum time a shard waits before flushing a partial batch")
+
+	scrapeErrorsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "scrape_errors_total"),
+		"Total number of failed scrapes for a target, by reason",
+		[]string{"target", "reason"}, nil,
+	)
+	scrapeDuration = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "scrape_duration_seconds"),
+		"How long the last scrape of a target took, in seconds",
+		[]string{"target"}, nil,
+	)
+	lastScrapeTimestamp = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "last_scrape_timestamp_seconds"),
+		"Unix timestamp of the last scrape attempt for a target",
+		[]string{"target"}, nil,
+	)
+)
+
+// scrapeJob is one unit of sharded scrape work.
+type scrapeJob struct {
+	target Target
+	ch     chan<- prometheus.Metric
+	wg     *sync.WaitGroup
+}
+
+// shard owns a subset of targets, selected by hashing the target name, and
+// scrapes them with a bounded in-flight budget.
+type shard struct {
+	jobs chan scrapeJob
+}
+
+// newShards starts n shard goroutines, each scraping with at most
+// concurrency scrapes in flight and flushing queued jobs in batches of up
+// to batchSize or after batchDeadline, whichever comes first - the same
+// flush-on-size-or-deadline shape as Prometheus's remote-write queue
+// manager. scrape performs one target's scrape; onResult is called once
+// per completed job with its outcome.
+func newShards(n, concurrency, batchSize int, batchDeadline time.Duration,
+	scrape func(Target, chan<- prometheus.Metric) (bool, string),
+	onResult func(Target, chan<- prometheus.Metric, bool, string, time.Duration)) []*shard {
+
+	shards := make([]*shard, n)
+	for i := range shards {
+		s := &shard{jobs: make(chan scrapeJob, batchSize)}
+		shards[i] = s
+		go runShard(s, concurrency, batchSize, batchDeadline, scrape, onResult)
+	}
+	return shards
+}
+
+func runShard(s *shard, concurrency, batchSize int, batchDeadline time.Duration,
+	scrape func(Target, chan<- prometheus.Metric) (bool, string),
+	onResult func(Target, chan<- prometheus.Metric, bool, string, time.Duration)) {
+
+	sem := make(chan struct{}, concurrency)
+	batch := make([]scrapeJob, 0, batchSize)
+	ticker := time.NewTicker(batchDeadline)
+	defer ticker.Stop()
+
+	flush := func() {
+		for _, job := range batch {
+			sem <- struct{}{}
+			go func(job scrapeJob) {
+				defer func() { <-sem }()
+				defer job.wg.Done()
+
+				start := time.Now()
+				ok, reason := scrape(job.target, job.ch)
+				onResult(job.target, job.ch, ok, reason, time.Since(start))
+			}(job)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case job, open := <-s.jobs:
+			if !open {
+				flush()
+				return
+			}
+			batch = append(batch, job)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				flush()
+			}
+		}
+	}
+}
+
+// shardIndex deterministically maps a target name onto one of n shards.
+func shardIndex(name string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32()) % n
+}
+
+// recordResult reports per-target scrape health: duration, last-scrape
+// timestamp, and - on failure - a running error count by reason.
+func (e *Exporter) recordResult(t Target, ch chan<- prometheus.Metric, ok bool, reason string, d time.Duration) {
+	ch <- prometheus.MustNewConstMetric(scrapeDuration, prometheus.GaugeValue, d.Seconds(), t.Name)
+	ch <- prometheus.MustNewConstMetric(lastScrapeTimestamp, prometheus.GaugeValue, float64(time.Now().Unix()), t.Name)
+
+	if ok {
+		return
+	}
+
+	key := t.Name + "|" + reason
+	counter, _ := e.errorCounts.LoadOrStore(key, new(uint64))
+	count := atomic.AddUint64(counter.(*uint64), 1)
+	ch <- prometheus.MustNewConstMetric(scrapeErrorsTotal, prometheus.CounterValue, float64(count), t.Name, reason)
+}
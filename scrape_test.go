@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestShardIndexDeterministic(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		if got := shardIndex("chain-a", 4); got != shardIndex("chain-a", 4) {
+			t.Fatalf("shardIndex is not deterministic: got %d", got)
+		}
+	}
+}
+
+func TestShardIndexInRange(t *testing.T) {
+	names := []string{"chain-a", "chain-b", "chain-c", "validator-1", ""}
+	for _, n := range names {
+		idx := shardIndex(n, 4)
+		if idx < 0 || idx >= 4 {
+			t.Errorf("shardIndex(%q, 4) = %d, want in [0,4)", n, idx)
+		}
+	}
+}
+
+func TestShardIndexSpreadsAcrossShards(t *testing.T) {
+	seen := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		seen[shardIndex(string(rune('a'+i)), 4)] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("shardIndex mapped 50 distinct names onto only %d shard(s)", len(seen))
+	}
+}
+
+func scrapeJobCounter(t *testing.T) (func(Target, chan<- prometheus.Metric) (bool, string), *int32Counter) {
+	counter := &int32Counter{}
+	scrape := func(Target, chan<- prometheus.Metric) (bool, string) {
+		counter.inc()
+		return true, ""
+	}
+	return scrape, counter
+}
+
+type int32Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *int32Counter) inc() {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+
+func (c *int32Counter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func TestRunShardFlushesOnBatchSize(t *testing.T) {
+	scrape, counter := scrapeJobCounter(t)
+	noopResult := func(Target, chan<- prometheus.Metric, bool, string, time.Duration) {}
+
+	shards := newShards(1, 2, 3, time.Hour, scrape, noopResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		shards[0].jobs <- scrapeJob{target: Target{Name: "t"}, wg: &wg}
+	}
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed once it reached batchSize")
+	}
+
+	if got := counter.get(); got != 3 {
+		t.Errorf("scrape called %d times, want 3", got)
+	}
+}
+
+func TestRunShardFlushesOnDeadline(t *testing.T) {
+	scrape, counter := scrapeJobCounter(t)
+	noopResult := func(Target, chan<- prometheus.Metric, bool, string, time.Duration) {}
+
+	shards := newShards(1, 2, 8, 20*time.Millisecond, scrape, noopResult)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	shards[0].jobs <- scrapeJob{target: Target{Name: "t"}, wg: &wg}
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("partial batch was not flushed after batchDeadline")
+	}
+
+	if got := counter.get(); got != 1 {
+		t.Errorf("scrape called %d times, want 1", got)
+	}
+}